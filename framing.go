@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// lengthFrameMagic is the first byte written by length-framing clients ahead of
+// the 4-byte big-endian size prefix. It falls outside any valid leading byte for
+// newline-delimited JSON text (whitespace, '{', '[', '"', digits, -, t, f, n),
+// so -framing=auto can tell the two framings apart by peeking one byte.
+const lengthFrameMagic = 0xBF
+
+// maxFrameSize guards against a corrupt or hostile length prefix turning into an
+// unbounded allocation.
+const maxFrameSize = 16 * 1024 * 1024
+
+// looksLikeLengthFrame reports whether b is the length-framing magic byte.
+func looksLikeLengthFrame(b byte) bool {
+	return b == lengthFrameMagic
+}
+
+// readLengthFramed reads one magic-byte + 4-byte big-endian length prefix + payload
+// frame from r, including the magic byte itself — callers must not discard it
+// first, even if they peeked at it to pick this framing in the first place.
+func readLengthFramed(r *bufio.Reader) ([]byte, error) {
+	magic, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("read frame magic: %w", err)
+	}
+	if !looksLikeLengthFrame(magic) {
+		return nil, fmt.Errorf("bad length frame magic 0x%02x", magic)
+	}
+
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, fmt.Errorf("read length prefix: %w", err)
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	if n > maxFrameSize {
+		return nil, fmt.Errorf("frame size %d exceeds max %d", n, maxFrameSize)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("read frame payload: %w", err)
+	}
+	return payload, nil
+}
+
+// decodePayload turns a raw event frame into the map[string]interface{} shape
+// EventBatcher.Add expects: frames whose first byte can start JSON text are
+// decoded as JSON, all others as MessagePack. There is no fallback between the
+// two; a frame that fails the codec picked for it is rejected.
+func decodePayload(raw []byte) (map[string]interface{}, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+	if looksLikeJSON(raw[0]) {
+		return unmarshalJSONMap(raw)
+	}
+	v, err := decodeMsgPack(raw)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack decode: %w", err)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack payload is not a map (got %T)", v)
+	}
+	return m, nil
+}
+
+func looksLikeJSON(b byte) bool {
+	switch b {
+	case '{', '[', '"', '-', 't', 'f', 'n', ' ', '\t', '\r', '\n':
+		return true
+	}
+	return b >= '0' && b <= '9'
+}
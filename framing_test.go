@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+// TestReadLengthFramedConsumesMagicByte guards the fix where explicit
+// -framing=length corrupted every frame by never discarding the magic byte
+// before the length prefix; readLengthFramed must consume it itself whether
+// or not the caller already peeked at it.
+func TestReadLengthFramedConsumesMagicByte(t *testing.T) {
+	payload := []byte(`{"k":"v"}`)
+	var buf bytes.Buffer
+	buf.WriteByte(lengthFrameMagic)
+	buf.Write([]byte{0x00, 0x00, 0x00, byte(len(payload))})
+	buf.Write(payload)
+
+	r := bufio.NewReader(&buf)
+	got, err := readLengthFramed(r)
+	if err != nil {
+		t.Fatalf("readLengthFramed: %v", err)
+	}
+	if string(got) != string(payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+}
+
+func TestReadLengthFramedRejectsBadMagic(t *testing.T) {
+	r := bufio.NewReader(bytes.NewReader([]byte{0x00, 0x00, 0x00, 0x00, 0x01}))
+	if _, err := readLengthFramed(r); err == nil {
+		t.Fatal("expected an error for a missing/wrong magic byte, got nil")
+	}
+}
+
+func TestReadLengthFramedRejectsOversizedFrame(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteByte(lengthFrameMagic)
+	buf.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	r := bufio.NewReader(&buf)
+	if _, err := readLengthFramed(r); err == nil {
+		t.Fatal("expected an error for a frame size over maxFrameSize, got nil")
+	}
+}
+
+func TestDecodePayloadDispatchesJSONAndMsgPack(t *testing.T) {
+	m, err := decodePayload([]byte(`{"a":1}`))
+	if err != nil || m["a"] != float64(1) {
+		t.Fatalf("JSON path: got %#v, err %v", m, err)
+	}
+
+	// fixmap{1}: "a" -> 1
+	m, err = decodePayload([]byte{0x81, 0xa1, 'a', 0x01})
+	if err != nil || m["a"] != int64(1) {
+		t.Fatalf("msgpack path: got %#v, err %v", m, err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// logFormat selects between the relay's original emoji-decorated console
+// output and structured JSON records ("json"), via -log-format.
+var logFormat = "text"
+
+type logRecord struct {
+	Level string `json:"level"`
+	Ts    string `json:"ts"`
+	UUID  string `json:"uuid,omitempty"`
+	Event string `json:"event,omitempty"`
+	Msg   string `json:"msg"`
+}
+
+// logf emits one log line. In text mode it prints the formatted msg followed by
+// a newline (msg is expected to carry its own emoji/formatting, matching the rest of the file);
+// in json mode it's a structured record suitable for a log shipper.
+func logf(level, event, uuid, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if logFormat != "json" {
+		fmt.Println(msg)
+		return
+	}
+	rec := logRecord{Level: level, Ts: time.Now().UTC().Format(time.RFC3339), UUID: uuid, Event: event, Msg: msg}
+	enc := json.NewEncoder(os.Stdout)
+	enc.Encode(rec)
+}
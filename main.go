@@ -2,7 +2,8 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -29,9 +30,26 @@ var (
 	recordFile     string
 	replayFile     string
 	replaySpeed    float64
+	replayStart    string
+	replayEnd      string
+	replayLoop     bool
+	replayFilter   string
+	replayControl  string
+	tlsCert        string
+	tlsKey         string
+	tlsCA          string
+	signKey        string
+	signAlg        string
+	keyRotate      time.Duration
+	framing        string
+	retryDir       string
+	retryMaxSizeMB int
+	retryMaxFiles  int
+	retryMaxAge    time.Duration
+	metricsAddr    string
+	sinkSpec       string
 	apiClient      = &http.Client{Timeout: 5 * time.Second}
 	configFileName = ".bar_uuid"
-	maxRetryAge    = 60 * time.Second
 )
 
 type RecordedEvent struct {
@@ -39,48 +57,67 @@ type RecordedEvent struct {
 	Data      map[string]interface{} `json:"d"`
 }
 
-type retryItem struct {
-	payload   []byte
-	timestamp time.Time
+type EventBatcher struct {
+	mu               sync.Mutex
+	buffer           []map[string]interface{}
+	idleTimer        *time.Timer
+	batchTimer       *time.Timer
+	isInBatchMode    bool
+	softTimeout      time.Duration
+	hardTimeout      time.Duration
+	uuid             string
+	verbose          bool
+	startTime        time.Time
+	totalEvents      int64
+	totalRequests    int64
+	totalBytes       int64
+	droppedEvents    int64
+	invalidEvents    int64 // TRACKING JSON ERRORS
+	recorder         *json.Encoder
+	recordMu         sync.Mutex
+	sinks            []*sinkEntry
+	batchStart       time.Time
+	batchSizeHist    *Histogram
+	flushLatencyHist *Histogram
 }
 
-type EventBatcher struct {
-	mu            sync.Mutex
-	buffer        []map[string]interface{}
-	idleTimer     *time.Timer
-	batchTimer    *time.Timer
-	isInBatchMode bool
-	softTimeout   time.Duration
-	hardTimeout   time.Duration
-	uuid          string
-	apiUrl        string
-	apiClient     *http.Client
-	verbose       bool
-	startTime     time.Time
-	totalEvents   int64
-	totalRequests int64
-	totalBytes    int64
-	droppedEvents int64
-	invalidEvents int64 // TRACKING JSON ERRORS
-	recorder      *json.Encoder
-	recordMu      sync.Mutex
-	retryQueue    []retryItem
-	retryMu       sync.Mutex
+// sinkEntry pairs a Sink with its own on-disk retry spool, so one slow or
+// down backend (e.g. kafka://) can't block or lose batches bound for the
+// others.
+type sinkEntry struct {
+	sink  Sink
+	spool *RetrySpool
 }
 
 // --- Logic ---
 
-func NewEventBatcher(u, url string, v bool, recPath string) *EventBatcher {
+// NewEventBatcher wires up sinks (each with an independent retry spool rooted
+// under retryDir) and the optional session recorder; the batching timers are
+// started later by Add.
+func NewEventBatcher(u string, v bool, recPath string, sinks []Sink, retryDir string, retryMaxSizeMB, retryMaxFiles int, retryMaxAge time.Duration) (*EventBatcher, error) {
 	eb := &EventBatcher{
-		buffer:      make([]map[string]interface{}, 0),
-		softTimeout: 100 * time.Millisecond,
-		hardTimeout: 250 * time.Millisecond,
-		uuid:        u,
-		apiUrl:      url,
-		apiClient:   apiClient,
-		verbose:     v,
-		startTime:   time.Now(),
-		retryQueue:  make([]retryItem, 0),
+		buffer:           make([]map[string]interface{}, 0),
+		softTimeout:      100 * time.Millisecond,
+		hardTimeout:      250 * time.Millisecond,
+		uuid:             u,
+		verbose:          v,
+		startTime:        time.Now(),
+		batchSizeHist:    NewHistogram([]float64{1, 2, 5, 10, 25, 50, 100, 250}),
+		flushLatencyHist: NewHistogram([]float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}),
+	}
+
+	for i, sink := range sinks {
+		spoolDir := filepath.Join(retryDir, fmt.Sprintf("sink-%d", i))
+		spool, err := NewRetrySpool(spoolDir, retryMaxSizeMB, retryMaxFiles, retryMaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("retry spool for %s: %w", sink.Name(), err)
+		}
+		if pending := spool.Pending(); pending > 0 {
+			logf("info", "startup", u, "♻️  Re-enqueued %d pending batch(es) for %s", pending, sink.Name())
+		}
+		entry := &sinkEntry{sink: sink, spool: spool}
+		eb.sinks = append(eb.sinks, entry)
+		go eb.runSinkRetryWorker(entry)
 	}
 
 	if recPath != "" {
@@ -89,57 +126,53 @@ func NewEventBatcher(u, url string, v bool, recPath string) *EventBatcher {
 		}
 		f, err := os.OpenFile(recPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
-			fmt.Printf("⚠️  Record error: %v\n", err)
+			logf("error", "record_error", u, "⚠️  Record error: %v", err)
 		} else {
 			eb.recorder = json.NewEncoder(f)
-			fmt.Printf("📂 Recording to: %s\n", recPath)
+			logf("info", "record_start", u, "📂 Recording to: %s", recPath)
 		}
 	}
-	go eb.retryWorker()
-	return eb
+	return eb, nil
 }
 
-func (b *EventBatcher) retryWorker() {
+// runSinkRetryWorker streams failed batches back out of entry's spool to
+// entry.sink, independently of every other sink's retry queue.
+func (b *EventBatcher) runSinkRetryWorker(entry *sinkEntry) {
 	for {
 		time.Sleep(5 * time.Second)
-		b.retryMu.Lock()
-		if len(b.retryQueue) == 0 {
-			b.retryMu.Unlock()
+
+		payload, count, enqueuedAt, ok, err := entry.spool.PopWithAge()
+		if err != nil {
+			if b.verbose {
+				logf("error", "retry_spool_error", b.uuid, "[!] Retry spool error (%s): %v", entry.sink.Name(), err)
+			}
 			continue
 		}
-		var valid []retryItem
-		now := time.Now()
-		for _, item := range b.retryQueue {
-			if now.Sub(item.timestamp) < maxRetryAge {
-				valid = append(valid, item)
-			} else {
-				b.mu.Lock()
-				b.droppedEvents++
-				b.mu.Unlock()
-			}
+		if !ok {
+			continue
 		}
-		b.retryQueue = valid
-		if len(b.retryQueue) > 0 {
-			item := b.retryQueue[0]
-			b.retryQueue = b.retryQueue[1:]
-			b.retryMu.Unlock()
-			b.sendToAPI(item.payload, true)
-		} else {
-			b.retryMu.Unlock()
+
+		if entry.spool.IsStale(enqueuedAt) {
+			b.mu.Lock()
+			b.droppedEvents += int64(count)
+			b.mu.Unlock()
+			continue
 		}
+
+		b.dispatchToSink(entry, payload, count, true, enqueuedAt)
 	}
 }
 
-func (b *EventBatcher) Add(jsonStr string) {
-	var data map[string]interface{}
+func (b *EventBatcher) Add(raw []byte) {
 	// FIX: Report JSON unmarshalling errors
-	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
+	data, err := decodePayload(raw)
+	if err != nil {
 		b.mu.Lock()
 		b.invalidEvents++
 		b.mu.Unlock()
 
 		if b.verbose {
-			fmt.Printf("\n[!] JSON Parse Error: %v | Input: %q\n", err, jsonStr)
+			logf("warn", "decode_error", b.uuid, "Decode Error: %v | Input: %q", err, raw)
 		}
 		return
 	}
@@ -157,6 +190,7 @@ func (b *EventBatcher) Add(jsonStr string) {
 	b.buffer = append(b.buffer, data)
 
 	if len(b.buffer) == 1 {
+		b.batchStart = time.Now()
 		b.isInBatchMode = false
 		if b.idleTimer != nil {
 			b.idleTimer.Stop()
@@ -213,53 +247,69 @@ func (b *EventBatcher) flushUnsafe() {
 	}
 
 	var payload []byte
-	if len(b.buffer) == 1 {
+	count := len(b.buffer)
+	if count == 1 {
 		payload, _ = json.Marshal(b.buffer[0])
 	} else {
 		payload, _ = json.Marshal(b.buffer)
 	}
 
-	go b.sendToAPI(payload, false)
+	b.batchSizeHist.Observe(float64(count))
+	if !b.batchStart.IsZero() {
+		b.flushLatencyHist.Observe(time.Since(b.batchStart).Seconds())
+	}
+
+	now := time.Now()
+	for _, entry := range b.sinks {
+		go b.dispatchToSink(entry, payload, count, false, now)
+	}
 	b.buffer = make([]map[string]interface{}, 0)
 	b.isInBatchMode = false
 
 	kb := float64(b.totalBytes) / 1024.0
-	fmt.Printf("\r🚀 [Relay] Events: %-6d | Req: %-4d | Sent: %-7.2f KB", b.totalEvents, b.totalRequests, kb)
+	if logFormat == "json" {
+		logf("info", "flush", b.uuid, "batch flushed: events=%d requests=%d sent_kb=%.2f", b.totalEvents, b.totalRequests, kb)
+	} else {
+		fmt.Printf("\r🚀 [Relay] Events: %-6d | Req: %-4d | Sent: %-7.2f KB", b.totalEvents, b.totalRequests, kb)
+	}
 }
 
-func (b *EventBatcher) sendToAPI(p []byte, retry bool) {
-	req, err := http.NewRequest("POST", b.apiUrl, bytes.NewBuffer(p))
+// dispatchToSink sends payload to entry.sink, queuing it back in entry's
+// retry spool on failure — including a failure on a retry attempt itself, so
+// a batch keeps getting retried until it succeeds or ages out via
+// -retry-max-age (checked by the caller before re-dispatching), rather than
+// being dropped after a single retry. enqueuedAt is the original time the
+// batch first failed, preserved across retries so the age cap is measured
+// from the first failure, not the most recent one.
+func (b *EventBatcher) dispatchToSink(entry *sinkEntry, payload []byte, count int, retry bool, enqueuedAt time.Time) {
+	err := entry.sink.Send(payload, BatchMeta{UUID: b.uuid, Count: count})
 	if err != nil {
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := apiClient.Do(req)
-	if err != nil || (resp != nil && resp.StatusCode >= 400) {
-		if !retry {
-			b.retryMu.Lock()
-			b.retryQueue = append(b.retryQueue, retryItem{payload: p, timestamp: time.Now()})
-			b.retryMu.Unlock()
+		if b.verbose {
+			logf("error", "sink_error", b.uuid, "[!] Sink %s error: %v", entry.sink.Name(), err)
 		}
-		if resp != nil {
-			resp.Body.Close()
+		if pushErr := entry.spool.PushAt(payload, count, enqueuedAt); pushErr != nil && b.verbose {
+			logf("error", "retry_spool_write_error", b.uuid, "[!] Retry spool write error (%s): %v", entry.sink.Name(), pushErr)
 		}
 		return
 	}
-	defer resp.Body.Close()
 
 	b.mu.Lock()
 	b.totalRequests++
-	b.totalBytes += int64(len(p))
+	b.totalBytes += int64(len(payload))
 	b.mu.Unlock()
 
 	if b.verbose {
-		fmt.Printf("\n[v] Sent %d bytes (Status: %d)\n", len(p), resp.StatusCode)
+		logf("info", "sent", b.uuid, "Sent %d bytes to %s", len(payload), entry.sink.Name())
 	}
 }
 
 func (b *EventBatcher) PrintFinalSummary() {
 	kb := float64(b.totalBytes) / 1024.0
+	if logFormat == "json" {
+		logf("info", "summary", b.uuid, "session summary: events=%d requests=%d sent_kb=%.2f dropped=%d invalid=%d",
+			b.totalEvents, b.totalRequests, kb, b.droppedEvents, b.invalidEvents)
+		return
+	}
 	fmt.Println("\n\n--- 🏁 Session Summary ---")
 	fmt.Printf("📈 Events:   %d\n🌐 Requests: %d\n💾 Data:     %.2f KB\n", b.totalEvents, b.totalRequests, kb)
 	if b.droppedEvents > 0 {
@@ -271,6 +321,57 @@ func (b *EventBatcher) PrintFinalSummary() {
 	fmt.Println("--------------------------")
 }
 
+// loadTLSConfig builds a *tls.Config for the ingest listener from -tls-cert/-tls-key,
+// requiring and verifying client certs against -tls-ca when provided.
+func loadTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load cert/key: %w", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if caFile != "" {
+		caBytes, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certs parsed from %s", caFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// newAPIClient returns the http.Client used for outbound API requests, configured to
+// present a client cert (mutual TLS) when -tls-cert/-tls-key are set.
+func newAPIClient(certFile, keyFile, caFile string) (*http.Client, error) {
+	if certFile == "" || keyFile == "" {
+		return &http.Client{Timeout: 5 * time.Second}, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load client cert/key: %w", err)
+	}
+	tlsCfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if caFile != "" {
+		caBytes, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certs parsed from %s", caFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsCfg},
+	}, nil
+}
+
 func getUUID() string {
 	home, _ := os.UserHomeDir()
 	path := filepath.Join(home, configFileName)
@@ -296,6 +397,25 @@ func main() {
 	flag.StringVar(&recordFile, "record", "", "Record to file (or 'auto')")
 	flag.StringVar(&replayFile, "replay", "", "Replay a .jsonl file")
 	flag.Float64Var(&replaySpeed, "speed", 1.0, "Replay speed (e.g. 2.0)")
+	flag.StringVar(&replayStart, "replay-start", "", "Skip replayed events before this RFC3339 timestamp")
+	flag.StringVar(&replayEnd, "replay-end", "", "Stop replay at this RFC3339 timestamp")
+	flag.BoolVar(&replayLoop, "replay-loop", false, "Restart the recording from the top when it ends")
+	flag.StringVar(&replayFilter, "replay-filter", "", "Only replay events matching field==value (or field=value)")
+	flag.StringVar(&replayControl, "replay-control", "", "Address for an interactive replay control port (pause/resume/seek/speed); empty disables it")
+	flag.StringVar(&tlsCert, "tls-cert", "", "TLS certificate (enables TLS on ingest + mTLS to upstream)")
+	flag.StringVar(&tlsKey, "tls-key", "", "TLS private key")
+	flag.StringVar(&tlsCA, "tls-ca", "", "CA bundle to verify ingest client certs / upstream API cert")
+	flag.StringVar(&signKey, "sign-key", "", "Key file for JWT-signing outbound batches (HMAC secret or RSA PEM)")
+	flag.StringVar(&signAlg, "sign-alg", "HS256", "JWT signing algorithm: HS256 or RS256")
+	flag.DurationVar(&keyRotate, "key-rotate", 0, "Reload the signing key on this interval (e.g. 1h); 0 disables")
+	flag.StringVar(&framing, "framing", "auto", "Ingest framing: newline, length, or auto (detect via magic byte)")
+	flag.StringVar(&retryDir, "retry-dir", "retry-queue", "Directory for the on-disk retry spool")
+	flag.IntVar(&retryMaxSizeMB, "retry-max-size", 10, "Rotate a retry spool segment after this many MB")
+	flag.IntVar(&retryMaxFiles, "retry-max-files", 10, "Keep at most this many retry spool segments")
+	flag.DurationVar(&retryMaxAge, "retry-max-age", 10*time.Minute, "Drop retry spool items older than this")
+	flag.StringVar(&logFormat, "log-format", "text", "Log output format: text (emoji console) or json (structured)")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "Address to serve Prometheus /metrics on (e.g. :9090); empty disables it")
+	flag.StringVar(&sinkSpec, "sink", "", "Comma-separated sinks (http://, file://, udp://, ws://, kafka://); empty defaults to -url")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of BAR Relay:\n")
@@ -308,7 +428,7 @@ func main() {
 	if reset {
 		home, _ := os.UserHomeDir()
 		os.Remove(filepath.Join(home, configFileName))
-		fmt.Println("✅ UUID cleared.")
+		logf("info", "reset", "", "✅ UUID cleared.")
 	}
 
 	u := uuid
@@ -316,62 +436,116 @@ func main() {
 		u = getUUID()
 	}
 
-	batcher := NewEventBatcher(u, apiUrl, verbose, recordFile)
+	client, err := newAPIClient(tlsCert, tlsKey, tlsCA)
+	if err != nil {
+		logf("error", "fatal", u, "❌ Fatal: %v", err)
+		return
+	}
+	apiClient = client
+
+	var signer *Signer
+	if signKey != "" {
+		signer, err = NewSigner(signKey, signAlg)
+		if err != nil {
+			logf("error", "fatal", u, "❌ Fatal: %v", err)
+			return
+		}
+		rotateStop := make(chan struct{})
+		signer.StartRotation(keyRotate, verbose, rotateStop)
+	}
 
-	// Shutdown handling
+	var sinks []Sink
+	if sinkSpec != "" {
+		sinks, err = ParseSinks(sinkSpec, signer)
+	} else {
+		sinks = []Sink{NewHTTPSink(apiUrl, apiClient, signer)}
+	}
+	if err != nil {
+		logf("error", "fatal", u, "❌ Fatal: %v", err)
+		return
+	}
+
+	batcher, err := NewEventBatcher(u, verbose, recordFile, sinks, retryDir, retryMaxSizeMB, retryMaxFiles, retryMaxAge)
+	if err != nil {
+		logf("error", "fatal", u, "❌ Fatal: %v", err)
+		return
+	}
+	if err := batcher.serveMetrics(metricsAddr); err != nil {
+		logf("error", "fatal", u, "❌ Fatal: %v", err)
+		return
+	}
+
+	// Shutdown + key-rotation signal handling. SIGHUP reloads the signing key
+	// in place without touching the in-flight retry queue.
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	go func() {
-		<-sigChan
-		batcher.PrintFinalSummary()
-		os.Exit(0)
+		for sig := range sigChan {
+			if sig == syscall.SIGHUP {
+				if signer != nil {
+					if err := signer.Reload(); err != nil {
+						logf("error", "key_reload_error", u, "[!] Key reload error: %v", err)
+					} else if verbose {
+						logf("info", "key_reloaded", u, "[v] Signing key reloaded")
+					}
+				}
+				continue
+			}
+			batcher.PrintFinalSummary()
+			os.Exit(0)
+		}
 	}()
 
-	// Updated Replay logic to handle unmarshal errors
 	if replayFile != "" {
-		go func() {
-			f, err := os.Open(replayFile)
+		var startTs, endTs time.Time
+		if replayStart != "" {
+			startTs, err = time.Parse(time.RFC3339, replayStart)
 			if err != nil {
-				fmt.Printf("❌ Replay error: %v\n", err)
+				logf("error", "fatal", u, "❌ Fatal: bad -replay-start: %v", err)
 				return
 			}
-			defer f.Close()
-
-			reader := bufio.NewReader(f)
-			var last time.Time
-			for {
-				line, err := reader.ReadString('\n')
-				if err != nil {
-					break
-				}
-				var rec RecordedEvent
-				if err := json.Unmarshal([]byte(line), &rec); err != nil {
-					batcher.mu.Lock()
-					batcher.invalidEvents++
-					batcher.mu.Unlock()
-					if verbose {
-						fmt.Printf("\n[!] Replay JSON Error: %v | Line: %q\n", err, line)
-					}
-					continue
-				}
-				if !last.IsZero() {
-					time.Sleep(time.Duration(float64(rec.Timestamp.Sub(last)) / replaySpeed))
-				}
-				raw, _ := json.Marshal(rec.Data)
-				batcher.Add(string(raw))
-				last = rec.Timestamp
+		}
+		if replayEnd != "" {
+			endTs, err = time.Parse(time.RFC3339, replayEnd)
+			if err != nil {
+				logf("error", "fatal", u, "❌ Fatal: bad -replay-end: %v", err)
+				return
+			}
+		}
+
+		rc := NewReplayController(replaySpeed, startTs, endTs, replayLoop, replayFilter)
+		if replayControl != "" {
+			if err := ServeReplayControl(replayControl, rc); err != nil {
+				logf("error", "fatal", u, "❌ Fatal: %v", err)
+				return
 			}
-			fmt.Println("\n🏁 Replay finished.")
-		}()
+		}
+		go runReplay(batcher, replayFile, rc, verbose)
 	}
 
 	addr := net.JoinHostPort(host, port)
-	l, err := net.Listen("tcp", addr)
-	if err != nil {
-		fmt.Printf("❌ Fatal: %v\n", err)
-		return
+	var l net.Listener
+	if tlsCert != "" && tlsKey != "" {
+		tlsCfg, err := loadTLSConfig(tlsCert, tlsKey, tlsCA)
+		if err != nil {
+			logf("error", "fatal", u, "❌ Fatal: %v", err)
+			return
+		}
+		l, err = tls.Listen("tcp", addr, tlsCfg)
+		if err != nil {
+			logf("error", "fatal", u, "❌ Fatal: %v", err)
+			return
+		}
+		logf("info", "listening", u, "📡 BAR Relay (TLS): %s", addr)
+	} else {
+		var err error
+		l, err = net.Listen("tcp", addr)
+		if err != nil {
+			logf("error", "fatal", u, "❌ Fatal: %v", err)
+			return
+		}
+		logf("info", "listening", u, "📡 BAR Relay: %s", addr)
 	}
-	fmt.Printf("📡 BAR Relay: %s\n", addr)
 
 	for {
 		conn, err := l.Accept()
@@ -379,21 +553,39 @@ func main() {
 			go func(c net.Conn) {
 				defer c.Close()
 				reader := bufio.NewReader(c)
+
+				lengthFramed := framing == "length"
+				if framing == "auto" {
+					first, err := reader.Peek(1)
+					if err != nil {
+						return
+					}
+					lengthFramed = looksLikeLengthFrame(first[0])
+				}
+
 				for {
-					line, err := reader.ReadString('\n')
+					var payload []byte
+					var err error
+
+					if lengthFramed {
+						payload, err = readLengthFramed(reader)
+					} else {
+						var line string
+						line, err = reader.ReadString('\n')
+						payload = []byte(strings.TrimSpace(line))
+					}
+
 					if err != nil {
 						if err != io.EOF && verbose {
-							fmt.Printf("\n[!] Conn Error: %v\n", err)
+							logf("error", "conn_error", batcher.uuid, "[!] Conn Error: %v", err)
 						}
 						break
 					}
-
-					line = strings.TrimSpace(line)
-					if line == "" {
+					if len(payload) == 0 {
 						continue
 					}
 
-					batcher.Add(line)
+					batcher.Add(payload)
 					c.Write([]byte("ACK\n"))
 				}
 			}(conn)
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Histogram is a minimal fixed-bucket Prometheus-style histogram. It avoids
+// pulling in the official client library for a handful of gauges the relay
+// actually needs.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64 // ascending upper bounds, not including +Inf
+	counts  []uint64  // per-bucket (not cumulative) observation counts
+	sum     float64
+	total   uint64
+}
+
+// NewHistogram builds a histogram with the given bucket upper bounds.
+func NewHistogram(buckets []float64) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &Histogram{buckets: sorted, counts: make([]uint64, len(sorted)+1)}
+}
+
+// Observe records a single sample.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sum += v
+	h.total++
+	idx := sort.SearchFloat64s(h.buckets, v)
+	if idx == len(h.buckets) {
+		idx = len(h.buckets) // overflow bucket (+Inf)
+	}
+	h.counts[idx]++
+}
+
+func (h *Histogram) writeTo(w io.Writer, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, le, cumulative)
+	}
+	cumulative += h.counts[len(h.buckets)]
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, cumulative)
+	fmt.Fprintf(w, "%s_sum %g\n", name, h.sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, h.total)
+}
+
+// metricsHandler renders the relay's counters and histograms in Prometheus
+// text exposition format.
+func (b *EventBatcher) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	b.mu.Lock()
+	events, requests, bytes := b.totalEvents, b.totalRequests, b.totalBytes
+	dropped, invalid := b.droppedEvents, b.invalidEvents
+	b.mu.Unlock()
+
+	writeCounter := func(name, help string, v int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	writeCounter("bar_relay_events_total", "Total events accepted", events)
+	writeCounter("bar_relay_requests_total", "Total successful batch deliveries across all sinks", requests)
+	writeCounter("bar_relay_bytes_total", "Total bytes delivered across all sinks", bytes)
+	writeCounter("bar_relay_dropped_events_total", "Events dropped as stale from the retry spool", dropped)
+	writeCounter("bar_relay_invalid_events_total", "Events that failed to decode", invalid)
+
+	var pending int
+	for _, entry := range b.sinks {
+		pending += entry.spool.Pending()
+	}
+	fmt.Fprintf(w, "# HELP bar_relay_retry_queue_depth Batches currently queued across all sink retry spools\n")
+	fmt.Fprintf(w, "# TYPE bar_relay_retry_queue_depth gauge\nbar_relay_retry_queue_depth %d\n", pending)
+
+	b.batchSizeHist.writeTo(w, "bar_relay_batch_size", "Number of events per flushed batch")
+	b.flushLatencyHist.writeTo(w, "bar_relay_flush_latency_seconds", "Time from buffering the first event in a batch to flush")
+}
+
+// serveMetrics binds addr and starts the /metrics HTTP endpoint in the
+// background; a no-op if addr is empty. Binding happens synchronously so a
+// collision (another process, -replay-control, or a ws:// sink) surfaces as
+// an error from here instead of the relay believing /metrics is up when it
+// never actually bound — same fix as NewWebSocketSink.
+func (b *EventBatcher) serveMetrics(addr string) error {
+	if addr == "" {
+		return nil
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", b.metricsHandler)
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			logf("error", "metrics", b.uuid, "metrics server stopped: %v", err)
+		}
+	}()
+	return nil
+}
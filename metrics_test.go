@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+// TestServeMetricsReportsBindFailure guards the fix where serveMetrics ran
+// http.ListenAndServe in a bare goroutine and only logged a bind failure, so
+// an -metrics-addr collision left the relay believing /metrics was up when
+// it never actually bound.
+func TestServeMetricsReportsBindFailure(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+
+	b := &EventBatcher{}
+	if err := b.serveMetrics(l.Addr().String()); err == nil {
+		t.Fatal("expected an error binding to an already-listening address, got nil")
+	}
+}
+
+func TestServeMetricsNoopOnEmptyAddr(t *testing.T) {
+	b := &EventBatcher{}
+	if err := b.serveMetrics(""); err != nil {
+		t.Fatalf("expected no error for an empty -metrics-addr, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// decodeMsgPack decodes a single MessagePack-encoded value from raw. It covers
+// the subset of the spec game clients actually send over the wire (maps,
+// arrays, strings, binary blobs, ints, floats, bool, nil) — enough for compact
+// telemetry without pulling in an external dependency.
+func decodeMsgPack(raw []byte) (interface{}, error) {
+	r := bytes.NewReader(raw)
+	return decodeMsgPackValue(r)
+}
+
+func decodeMsgPackValue(r *bytes.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f: // positive fixint
+		return int64(b), nil
+	case b >= 0xe0: // negative fixint
+		return int64(int8(b)), nil
+	case b >= 0x80 && b <= 0x8f: // fixmap
+		return decodeMsgPackMap(r, int(b&0x0f))
+	case b >= 0x90 && b <= 0x9f: // fixarray
+		return decodeMsgPackArray(r, int(b&0x0f))
+	case b >= 0xa0 && b <= 0xbf: // fixstr
+		return readMsgPackString(r, int(b&0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4: // bin8
+		n, err := readUint(r, 1)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackBytes(r, int(n))
+	case 0xc5: // bin16
+		n, err := readUint(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackBytes(r, int(n))
+	case 0xc6: // bin32
+		n, err := readUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackBytes(r, int(n))
+	case 0xca: // float32
+		n, err := readUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 0xcb: // float64
+		n, err := readUint(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(n), nil
+	case 0xcc: // uint8
+		n, err := readUint(r, 1)
+		return int64(n), err
+	case 0xcd: // uint16
+		n, err := readUint(r, 2)
+		return int64(n), err
+	case 0xce: // uint32
+		n, err := readUint(r, 4)
+		return int64(n), err
+	case 0xcf: // uint64
+		n, err := readUint(r, 8)
+		return int64(n), err
+	case 0xd0: // int8
+		n, err := readUint(r, 1)
+		return int64(int8(n)), err
+	case 0xd1: // int16
+		n, err := readUint(r, 2)
+		return int64(int16(n)), err
+	case 0xd2: // int32
+		n, err := readUint(r, 4)
+		return int64(int32(n)), err
+	case 0xd3: // int64
+		n, err := readUint(r, 8)
+		return int64(n), err
+	case 0xd9: // str8
+		n, err := readUint(r, 1)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackString(r, int(n))
+	case 0xda: // str16
+		n, err := readUint(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackString(r, int(n))
+	case 0xdb: // str32
+		n, err := readUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackString(r, int(n))
+	case 0xdc: // array16
+		n, err := readUint(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackArray(r, int(n))
+	case 0xdd: // array32
+		n, err := readUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackArray(r, int(n))
+	case 0xde: // map16
+		n, err := readUint(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackMap(r, int(n))
+	case 0xdf: // map32
+		n, err := readUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackMap(r, int(n))
+	}
+
+	return nil, fmt.Errorf("unsupported msgpack type byte 0x%02x", b)
+}
+
+func readUint(r *bytes.Reader, n int) (uint64, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range buf {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// checkMsgPackLen rejects a decoded length before it's used to size a make()
+// call: n can be attacker-controlled (up to ~4.29 billion for the *32
+// variants) and r.Len() is however many bytes are actually left to read, so
+// this catches a corrupt/hostile length long before it turns into a
+// multi-gigabyte allocation attempt.
+func checkMsgPackLen(r *bytes.Reader, n int) error {
+	if n < 0 || int64(n) > int64(r.Len()) {
+		return fmt.Errorf("msgpack length %d exceeds remaining input (%d bytes)", n, r.Len())
+	}
+	return nil
+}
+
+func readMsgPackBytes(r *bytes.Reader, n int) ([]byte, error) {
+	if err := checkMsgPackLen(r, n); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func readMsgPackString(r *bytes.Reader, n int) (string, error) {
+	buf, err := readMsgPackBytes(r, n)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func decodeMsgPackArray(r *bytes.Reader, n int) ([]interface{}, error) {
+	// Every element takes at least one byte on the wire, so n can never
+	// legitimately exceed the bytes left to read; reject before make().
+	if err := checkMsgPackLen(r, n); err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		v, err := decodeMsgPackValue(r)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func decodeMsgPackMap(r *bytes.Reader, n int) (map[string]interface{}, error) {
+	// Same reasoning as decodeMsgPackArray: each key+value pair takes at
+	// least two bytes on the wire, so this bounds the size hint before it
+	// reaches make().
+	if err := checkMsgPackLen(r, n*2); err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := decodeMsgPackValue(r)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack map key is not a string (got %T)", k)
+		}
+		v, err := decodeMsgPackValue(r)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+// unmarshalJSONMap is the JSON-path counterpart of decodeMsgPack, kept here so
+// decodePayload has one symmetric pair of codecs to call.
+func unmarshalJSONMap(raw []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDecodeMsgPackValues(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []byte
+		want interface{}
+	}{
+		{"positive fixint", []byte{0x05}, int64(5)},
+		{"negative fixint", []byte{0xff}, int64(-1)},
+		{"fixstr", []byte{0xa3, 'f', 'o', 'o'}, "foo"},
+		{"bool true", []byte{0xc3}, true},
+		{"uint8", []byte{0xcc, 0x2a}, int64(42)},
+		{"fixarray of ints", []byte{0x92, 0x01, 0x02}, []interface{}{int64(1), int64(2)}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := decodeMsgPack(c.in)
+			if err != nil {
+				t.Fatalf("decodeMsgPack(%v): %v", c.in, err)
+			}
+			switch want := c.want.(type) {
+			case []interface{}:
+				gotArr, ok := got.([]interface{})
+				if !ok || len(gotArr) != len(want) {
+					t.Fatalf("got %#v, want %#v", got, want)
+				}
+				for i := range want {
+					if gotArr[i] != want[i] {
+						t.Fatalf("element %d: got %#v want %#v", i, gotArr[i], want[i])
+					}
+				}
+			default:
+				if got != c.want {
+					t.Fatalf("got %#v, want %#v", got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeMsgPackFixMap(t *testing.T) {
+	// fixmap{1}: "k" -> "v"
+	raw := []byte{0x81, 0xa1, 'k', 0xa1, 'v'}
+	got, err := decodeMsgPack(raw)
+	if err != nil {
+		t.Fatalf("decodeMsgPack: %v", err)
+	}
+	m, ok := got.(map[string]interface{})
+	if !ok || m["k"] != "v" {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+// TestDecodeMsgPackRejectsOversizedLength guards the fix for corrupt/hostile
+// length prefixes (str/bin/array/map 8/16/32 variants) being used to size a
+// make() call before checking there's actually enough input left to fill it.
+func TestDecodeMsgPackRejectsOversizedLength(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []byte
+	}{
+		{"array32 claims 4 billion elements", []byte{0xdd, 0xff, 0xff, 0xff, 0xf0}},
+		{"map32 claims 4 billion pairs", []byte{0xdf, 0xff, 0xff, 0xff, 0xf0}},
+		{"str32 claims 255-byte string with no payload", []byte{0xdb, 0x00, 0x00, 0x00, 0xff}},
+		{"bin32 claims 255-byte blob with no payload", []byte{0xc6, 0x00, 0x00, 0x00, 0xff}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := decodeMsgPack(c.in); err == nil {
+				t.Fatalf("expected an error for an oversized/impossible length, got nil")
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONMap(t *testing.T) {
+	m, err := unmarshalJSONMap([]byte(`{"a":1,"b":"two"}`))
+	if err != nil {
+		t.Fatalf("unmarshalJSONMap: %v", err)
+	}
+	if m["a"] != float64(1) || m["b"] != "two" {
+		t.Fatalf("unexpected map: %#v", m)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayController owns the playback state for a replay session: pause/resume,
+// speed, and a pending seek target. Readers in one process that share a
+// controller share its pause state and speed, but each paces itself from its
+// own position in the recording, and a seek target is consumed by the first
+// reader that picks it up.
+type ReplayController struct {
+	mu         sync.Mutex
+	speed      float64
+	paused     bool
+	start      time.Time
+	end        time.Time
+	loop       bool
+	filter     string
+	seekTarget time.Time
+}
+
+// NewReplayController builds a controller bounded by [start, end] (either may
+// be zero to mean unbounded), looping the recording when loop is true, and
+// only emitting events matching filter (empty matches everything).
+func NewReplayController(speed float64, start, end time.Time, loop bool, filter string) *ReplayController {
+	return &ReplayController{speed: speed, start: start, end: end, loop: loop, filter: filter}
+}
+
+// Pause stops the virtual clock from advancing.
+func (c *ReplayController) Pause() {
+	c.mu.Lock()
+	c.paused = true
+	c.mu.Unlock()
+}
+
+// Resume restarts the virtual clock.
+func (c *ReplayController) Resume() {
+	c.mu.Lock()
+	c.paused = false
+	c.mu.Unlock()
+}
+
+// SetSpeed changes the playback rate (e.g. 2.0 = twice as fast).
+func (c *ReplayController) SetSpeed(speed float64) {
+	c.mu.Lock()
+	c.speed = speed
+	c.mu.Unlock()
+}
+
+// Seek requests a jump to ts: replay fast-forwards through (unsent) events
+// until reaching it, then resumes normal pacing.
+func (c *ReplayController) Seek(ts time.Time) {
+	c.mu.Lock()
+	c.seekTarget = ts
+	c.mu.Unlock()
+}
+
+// takeSeek returns a pending seek target, if any, and clears it.
+func (c *ReplayController) takeSeek() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seekTarget.IsZero() {
+		return time.Time{}, false
+	}
+	t := c.seekTarget
+	c.seekTarget = time.Time{}
+	return t, true
+}
+
+// InBounds reports whether ts falls within -replay-start/-replay-end.
+func (c *ReplayController) InBounds(ts time.Time) bool {
+	if !c.start.IsZero() && ts.Before(c.start) {
+		return false
+	}
+	if !c.end.IsZero() && ts.After(c.end) {
+		return false
+	}
+	return true
+}
+
+// Matches applies -replay-filter to a decoded event. The filter is a single
+// "field==value" or "field=value" comparison (stringified) — enough to pick
+// out one event type from a recording without pulling in a jq implementation.
+func (c *ReplayController) Matches(data map[string]interface{}) bool {
+	if c.filter == "" {
+		return true
+	}
+	key, val, ok := splitFilter(c.filter)
+	if !ok {
+		return true
+	}
+	v, present := data[key]
+	if !present {
+		return false
+	}
+	return fmt.Sprint(v) == val
+}
+
+func splitFilter(filter string) (key, val string, ok bool) {
+	sep := "=="
+	parts := strings.SplitN(filter, sep, 2)
+	if len(parts) != 2 {
+		sep = "="
+		parts = strings.SplitN(filter, sep, 2)
+	}
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
+// Sleep waits gap/speed, polling for pause in small increments so a paused
+// controller can be resumed without waiting out the whole gap first.
+func (c *ReplayController) Sleep(gap time.Duration) {
+	if gap <= 0 {
+		return
+	}
+	c.mu.Lock()
+	speed := c.speed
+	c.mu.Unlock()
+	if speed <= 0 {
+		speed = 1
+	}
+
+	const tick = 50 * time.Millisecond
+	remaining := time.Duration(float64(gap) / speed)
+	for remaining > 0 {
+		c.mu.Lock()
+		paused := c.paused
+		c.mu.Unlock()
+		if paused {
+			time.Sleep(tick)
+			continue
+		}
+		step := tick
+		if remaining < tick {
+			step = remaining
+		}
+		time.Sleep(step)
+		remaining -= step
+	}
+}
+
+// runReplay streams replayFile into batcher under rc's virtual clock,
+// restarting from the top whenever rc.loop is set.
+func runReplay(batcher *EventBatcher, replayFile string, rc *ReplayController, verbose bool) {
+	for {
+		replayOnce(batcher, replayFile, rc, verbose)
+		if !rc.loop {
+			break
+		}
+	}
+	logf("info", "replay_finished", batcher.uuid, "🏁 Replay finished.")
+}
+
+func replayOnce(batcher *EventBatcher, replayFile string, rc *ReplayController, verbose bool) {
+	f, err := os.Open(replayFile)
+	if err != nil {
+		logf("error", "replay_error", batcher.uuid, "❌ Replay error: %v", err)
+		return
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var last time.Time
+	var seeking bool
+	var seekTarget time.Time
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		var rec RecordedEvent
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			batcher.mu.Lock()
+			batcher.invalidEvents++
+			batcher.mu.Unlock()
+			if verbose {
+				logf("warn", "replay_decode_error", batcher.uuid, "Replay JSON Error: %v | Line: %q", err, line)
+			}
+			continue
+		}
+
+		if t, ok := rc.takeSeek(); ok {
+			seeking = true
+			seekTarget = t
+		}
+		if seeking {
+			if rec.Timestamp.Before(seekTarget) {
+				last = rec.Timestamp
+				continue
+			}
+			seeking = false
+			last = rec.Timestamp // resume pacing from here, skipping the jumped-over gap
+		}
+
+		if !rc.InBounds(rec.Timestamp) {
+			if !rc.end.IsZero() && rec.Timestamp.After(rc.end) {
+				break
+			}
+			last = rec.Timestamp
+			continue
+		}
+
+		if !last.IsZero() {
+			rc.Sleep(rec.Timestamp.Sub(last))
+		}
+		last = rec.Timestamp
+
+		if !rc.Matches(rec.Data) {
+			continue
+		}
+
+		raw, _ := json.Marshal(rec.Data)
+		batcher.Add(raw)
+	}
+}
+
+// ServeReplayControl runs a line-oriented TCP control port for rc, accepting
+// "pause", "resume", "seek <RFC3339>", and "speed <float>".
+func ServeReplayControl(addr string, rc *ReplayController) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("replay control listen: %w", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				continue
+			}
+			go handleReplayControlConn(conn, rc)
+		}
+	}()
+	return nil
+}
+
+func handleReplayControlConn(conn net.Conn, rc *ReplayController) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "pause":
+			rc.Pause()
+			conn.Write([]byte("OK\n"))
+		case "resume":
+			rc.Resume()
+			conn.Write([]byte("OK\n"))
+		case "seek":
+			if len(fields) < 2 {
+				conn.Write([]byte("ERR: usage: seek <RFC3339>\n"))
+				continue
+			}
+			ts, err := time.Parse(time.RFC3339, fields[1])
+			if err != nil {
+				conn.Write([]byte(fmt.Sprintf("ERR: %v\n", err)))
+				continue
+			}
+			rc.Seek(ts)
+			conn.Write([]byte("OK\n"))
+		case "speed":
+			if len(fields) < 2 {
+				conn.Write([]byte("ERR: usage: speed <float>\n"))
+				continue
+			}
+			f, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				conn.Write([]byte(fmt.Sprintf("ERR: %v\n", err)))
+				continue
+			}
+			rc.SetSpeed(f)
+			conn.Write([]byte("OK\n"))
+		default:
+			conn.Write([]byte("ERR: unknown command\n"))
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spoolRecord is one pending batch on disk, persisted as a single JSON line.
+type spoolRecord struct {
+	Timestamp time.Time       `json:"t"`
+	Count     int             `json:"count"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// RetrySpool is a lumberjack-style rotating append-only queue for batches that
+// failed to POST. Unlike the old in-memory retryQueue, it survives a relay
+// restart and is bounded by size/file-count/age instead of growing forever
+// during a long API outage. Records already claimed into popBuf are no longer
+// on disk, so those not yet retried are lost if the relay stops.
+type RetrySpool struct {
+	mu       sync.Mutex
+	dir      string
+	maxSize  int64 // bytes, per file
+	maxFiles int
+	maxAge   time.Duration
+	curIndex int
+	curFile  *os.File
+	curEnc   *json.Encoder
+	curSize  int64
+
+	// popBuf holds the still-unpopped records of the oldest segment once
+	// PopWithAge has claimed it (see loadNextSegmentLocked) — claiming a
+	// whole segment into memory up front means draining it is O(n), not
+	// the O(n^2) of re-reading and rewriting the remainder on every pop.
+	popBuf []spoolRecord
+
+	// pendingCount is the total record count across the whole spool
+	// (on disk plus popBuf), maintained incrementally on Push/PushAt/Pop
+	// so Pending() is O(1) instead of re-scanning every segment — it's
+	// scraped on every /metrics hit, which is exactly when the spool is
+	// likely to be largest.
+	pendingCount int
+}
+
+const retryFilePrefix = "retry-"
+const retryFileSuffix = ".jsonl"
+
+// NewRetrySpool opens (creating if needed) the spool directory and resumes
+// writing at the newest existing segment, rotating immediately if it is
+// already past maxSizeMB.
+func NewRetrySpool(dir string, maxSizeMB int, maxFiles int, maxAge time.Duration) (*RetrySpool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create retry dir: %w", err)
+	}
+	s := &RetrySpool{
+		dir:      dir,
+		maxSize:  int64(maxSizeMB) * 1024 * 1024,
+		maxFiles: maxFiles,
+		maxAge:   maxAge,
+	}
+
+	files, err := s.segmentFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		s.curIndex = 1
+	} else {
+		last := files[len(files)-1]
+		s.curIndex = s.indexOf(last)
+		if err := s.openForAppend(last); err != nil {
+			return nil, err
+		}
+		if s.maxSize > 0 && s.curSize >= s.maxSize {
+			s.curFile.Close()
+			s.curFile = nil
+			s.curIndex++
+		}
+	}
+
+	s.pruneLocked()
+	if err := s.recomputePendingLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// recomputePendingLocked scans every segment once to seed pendingCount. It's
+// only ever called from NewRetrySpool, so the one-time O(n) cost doesn't
+// recur on the hot Pending()/PopWithAge paths.
+func (s *RetrySpool) recomputePendingLocked() error {
+	files, err := s.segmentFiles()
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, f := range files {
+		recs, err := readSpoolRecords(f)
+		if err != nil {
+			return err
+		}
+		total += len(recs)
+	}
+	s.pendingCount = total
+	return nil
+}
+
+func (s *RetrySpool) segmentPath(index int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%06d%s", retryFilePrefix, index, retryFileSuffix))
+}
+
+func (s *RetrySpool) indexOf(path string) int {
+	base := filepath.Base(path)
+	base = strings.TrimPrefix(base, retryFilePrefix)
+	base = strings.TrimSuffix(base, retryFileSuffix)
+	n, _ := strconv.Atoi(base)
+	return n
+}
+
+// segmentFiles returns spool segments sorted oldest (lowest index) first.
+func (s *RetrySpool) segmentFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read retry dir: %w", err)
+	}
+	var out []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if strings.HasPrefix(name, retryFilePrefix) && strings.HasSuffix(name, retryFileSuffix) {
+			out = append(out, filepath.Join(s.dir, name))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return s.indexOf(out[i]) < s.indexOf(out[j]) })
+	return out, nil
+}
+
+func (s *RetrySpool) openForAppend(path string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open retry segment: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat retry segment: %w", err)
+	}
+	s.curFile = f
+	s.curEnc = json.NewEncoder(f)
+	s.curSize = info.Size()
+	return nil
+}
+
+// Push appends a newly-failed batch to the current segment, stamping it with
+// the current time, rotating to a new segment when -retry-max-size is
+// exceeded and pruning old segments by -retry-max-files / -retry-max-age.
+func (s *RetrySpool) Push(payload []byte, count int) error {
+	return s.PushAt(payload, count, time.Now())
+}
+
+// PushAt re-queues a batch that failed a retry attempt, preserving its
+// original enqueue time ts rather than resetting it — otherwise a batch that
+// keeps failing would never age out via -retry-max-age, since every retry
+// would push its deadline back out.
+func (s *RetrySpool) PushAt(payload []byte, count int, ts time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.curFile == nil {
+		if err := s.openForAppend(s.segmentPath(s.curIndex)); err != nil {
+			return err
+		}
+	}
+
+	rec := spoolRecord{Timestamp: ts, Count: count, Payload: payload}
+	if err := s.curEnc.Encode(rec); err != nil {
+		return fmt.Errorf("write retry segment: %w", err)
+	}
+	line, _ := json.Marshal(rec)
+	s.curSize += int64(len(line)) + 1
+	s.pendingCount++
+
+	if s.maxSize > 0 && s.curSize >= s.maxSize {
+		s.curFile.Close()
+		s.curFile = nil
+		s.curIndex++
+	}
+
+	s.pruneLocked()
+	return nil
+}
+
+// pruneLocked deletes segments past -retry-max-files or older than
+// -retry-max-age, keeping pendingCount in sync with what's actually dropped.
+// It never drops the segment s.curFile currently has open for append — that
+// file's mtime predates every record written to it since, so on a restart
+// after a long outage it can already be older than -retry-max-age despite
+// being actively written to; pruning it out from under the open handle would
+// leave every subsequent PushAt silently "succeeding" into an unlinked inode.
+// Must be called with s.mu held.
+func (s *RetrySpool) pruneLocked() {
+	files, err := s.segmentFiles()
+	if err != nil {
+		return
+	}
+
+	var activePath string
+	if s.curFile != nil {
+		activePath = s.segmentPath(s.curIndex)
+	}
+
+	dropSegment := func(f string) {
+		if recs, err := readSpoolRecords(f); err == nil {
+			s.pendingCount -= len(recs)
+		}
+		os.Remove(f)
+	}
+
+	if s.maxAge > 0 {
+		cutoff := time.Now().Add(-s.maxAge)
+		var kept []string
+		for _, f := range files {
+			if f == activePath {
+				kept = append(kept, f)
+				continue
+			}
+			info, err := os.Stat(f)
+			if err == nil && info.ModTime().Before(cutoff) {
+				dropSegment(f)
+				continue
+			}
+			kept = append(kept, f)
+		}
+		files = kept
+	}
+
+	if s.maxFiles > 0 && len(files) > s.maxFiles {
+		var droppable []string
+		for _, f := range files {
+			if f != activePath {
+				droppable = append(droppable, f)
+			}
+		}
+		excess := len(files) - s.maxFiles
+		if excess > len(droppable) {
+			excess = len(droppable)
+		}
+		for _, f := range droppable[:excess] {
+			dropSegment(f)
+		}
+	}
+}
+
+// PopWithAge removes and returns the oldest pending record across all
+// segments, along with the time it was originally enqueued. It reports
+// ok=false when the spool is empty.
+func (s *RetrySpool) PopWithAge() (payload []byte, count int, ts time.Time, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.popBuf) == 0 {
+		if err := s.loadNextSegmentLocked(); err != nil {
+			return nil, 0, time.Time{}, false, err
+		}
+		if len(s.popBuf) == 0 {
+			return nil, 0, time.Time{}, false, nil
+		}
+	}
+
+	head := s.popBuf[0]
+	s.popBuf = s.popBuf[1:]
+	s.pendingCount--
+
+	return head.Payload, head.Count, head.Timestamp, true, nil
+}
+
+// loadNextSegmentLocked claims the oldest on-disk segment into popBuf in one
+// read, then removes the file — draining a segment is then a sequence of
+// cheap in-memory pops instead of a read-and-rewrite of the remainder on
+// every single PopWithAge call. Must be called with s.mu held and popBuf
+// empty.
+func (s *RetrySpool) loadNextSegmentLocked() error {
+	files, err := s.segmentFiles()
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	oldest := files[0]
+
+	writingCurrent := s.curFile != nil && oldest == s.segmentPath(s.curIndex)
+	if writingCurrent {
+		s.curFile.Close()
+		s.curFile = nil
+	}
+
+	recs, err := readSpoolRecords(oldest)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(oldest); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove drained retry segment: %w", err)
+	}
+	if writingCurrent {
+		s.curIndex++
+	}
+
+	s.popBuf = recs
+	return nil
+}
+
+// IsStale reports whether a popped record's timestamp is older than the
+// spool's -retry-max-age, meaning it should be dropped instead of retried.
+func (s *RetrySpool) IsStale(ts time.Time) bool {
+	if s.maxAge <= 0 {
+		return false
+	}
+	return time.Since(ts) > s.maxAge
+}
+
+func readSpoolRecords(path string) ([]spoolRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open retry segment: %w", err)
+	}
+	defer f.Close()
+
+	var recs []spoolRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxFrameSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec spoolRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			continue // skip a corrupt line rather than losing the whole segment
+		}
+		recs = append(recs, rec)
+	}
+	return recs, scanner.Err()
+}
+
+// Pending reports the number of records currently queued (on disk plus any
+// already claimed into popBuf), used at startup to log how many batches were
+// re-enqueued from a prior run and scraped on every /metrics hit — so it
+// reads the incrementally-maintained pendingCount rather than re-scanning
+// every segment.
+func (s *RetrySpool) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pendingCount
+}
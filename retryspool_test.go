@@ -0,0 +1,162 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetrySpoolPushPopRoundTrip(t *testing.T) {
+	s, err := NewRetrySpool(t.TempDir(), 10, 5, 0)
+	if err != nil {
+		t.Fatalf("NewRetrySpool: %v", err)
+	}
+
+	if err := s.Push([]byte(`{"a":1}`), 1); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if err := s.Push([]byte(`{"a":2}`), 2); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if got := s.Pending(); got != 2 {
+		t.Fatalf("Pending() = %d, want 2", got)
+	}
+
+	payload, count, _, ok, err := s.PopWithAge()
+	if err != nil || !ok {
+		t.Fatalf("PopWithAge: ok=%v err=%v", ok, err)
+	}
+	if string(payload) != `{"a":1}` || count != 1 {
+		t.Fatalf("got payload %q count %d, want first-pushed record (FIFO)", payload, count)
+	}
+	if got := s.Pending(); got != 1 {
+		t.Fatalf("Pending() after one pop = %d, want 1", got)
+	}
+
+	_, _, _, ok, err = s.PopWithAge()
+	if err != nil || !ok {
+		t.Fatalf("second PopWithAge: ok=%v err=%v", ok, err)
+	}
+	if got := s.Pending(); got != 0 {
+		t.Fatalf("Pending() after draining = %d, want 0", got)
+	}
+
+	_, _, _, ok, err = s.PopWithAge()
+	if err != nil || ok {
+		t.Fatalf("PopWithAge on empty spool: ok=%v err=%v, want ok=false", ok, err)
+	}
+}
+
+func TestRetrySpoolReopensAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	s1, err := NewRetrySpool(dir, 10, 5, 0)
+	if err != nil {
+		t.Fatalf("NewRetrySpool: %v", err)
+	}
+	if err := s1.Push([]byte(`"payload"`), 1); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	s2, err := NewRetrySpool(dir, 10, 5, 0)
+	if err != nil {
+		t.Fatalf("NewRetrySpool (reopen): %v", err)
+	}
+	if got := s2.Pending(); got != 1 {
+		t.Fatalf("Pending() after reopen = %d, want 1 (re-enqueued from disk)", got)
+	}
+}
+
+// TestRetrySpoolPushAtPreservesEnqueueTime guards the fix where re-queuing a
+// failed retry reset its timestamp to time.Now(), which meant a batch that
+// kept failing would never age out via -retry-max-age.
+func TestRetrySpoolPushAtPreservesEnqueueTime(t *testing.T) {
+	s, err := NewRetrySpool(t.TempDir(), 10, 5, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRetrySpool: %v", err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := s.PushAt([]byte(`"stale"`), 1, old); err != nil {
+		t.Fatalf("PushAt: %v", err)
+	}
+
+	_, _, ts, ok, err := s.PopWithAge()
+	if err != nil || !ok {
+		t.Fatalf("PopWithAge: ok=%v err=%v", ok, err)
+	}
+	if !ts.Equal(old) {
+		t.Fatalf("popped timestamp = %v, want original enqueue time %v", ts, old)
+	}
+	if !s.IsStale(ts) {
+		t.Fatal("expected a 2-hour-old record to be stale under a 1-hour max age")
+	}
+}
+
+// TestRetrySpoolSurvivesRestartPastMaxAge guards the fix where pruneLocked,
+// run right after reopening the newest on-disk segment for append, age-out
+// pruned that segment from under the open *os.File when the relay had been
+// down longer than -retry-max-age — leaving every subsequent write silently
+// "succeeding" into an unlinked inode.
+func TestRetrySpoolSurvivesRestartPastMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	maxAge := 50 * time.Millisecond
+
+	s1, err := NewRetrySpool(dir, 10, 5, maxAge)
+	if err != nil {
+		t.Fatalf("NewRetrySpool: %v", err)
+	}
+	if err := s1.Push([]byte(`"first"`), 1); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	time.Sleep(2 * maxAge)
+
+	// Reopening resumes appending to the same (now stale-by-mtime) segment,
+	// then immediately prunes — this is where the bug unlinked it.
+	s2, err := NewRetrySpool(dir, 10, 5, maxAge)
+	if err != nil {
+		t.Fatalf("NewRetrySpool (reopen): %v", err)
+	}
+	if err := s2.Push([]byte(`"second"`), 2); err != nil {
+		t.Fatalf("Push after reopen: %v", err)
+	}
+
+	files, err := s2.segmentFiles()
+	if err != nil {
+		t.Fatalf("segmentFiles: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("active segment was pruned out from under the open file handle")
+	}
+
+	s3, err := NewRetrySpool(dir, 10, 5, maxAge)
+	if err != nil {
+		t.Fatalf("NewRetrySpool (third open): %v", err)
+	}
+	if got := s3.Pending(); got != 2 {
+		t.Fatalf("Pending() after restart = %d, want 2 (both pushed records present on disk)", got)
+	}
+}
+
+func TestRetrySpoolPruneByMaxFiles(t *testing.T) {
+	// maxSizeMB=0 disables size-based rotation, so set a 1-byte maxSize
+	// directly to force a rotation on every Push.
+	s, err := NewRetrySpool(t.TempDir(), 0, 2, 0)
+	if err != nil {
+		t.Fatalf("NewRetrySpool: %v", err)
+	}
+	s.maxSize = 1 // force a rotation on every push
+
+	for i := 0; i < 5; i++ {
+		if err := s.Push([]byte("1"), 1); err != nil {
+			t.Fatalf("Push %d: %v", i, err)
+		}
+	}
+
+	files, err := s.segmentFiles()
+	if err != nil {
+		t.Fatalf("segmentFiles: %v", err)
+	}
+	if len(files) > 2 {
+		t.Fatalf("expected at most 2 segments after pruning, got %d", len(files))
+	}
+}
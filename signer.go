@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signer wraps outbound batch payloads in a compact JWT so the upstream API can
+// reject stale or forged batches. The signing key can be rotated at runtime via
+// Reload, either on a timer (-key-rotate) or in response to SIGHUP.
+type Signer struct {
+	mu      sync.RWMutex
+	alg     string // "HS256" or "RS256"
+	keyPath string
+	hmacKey []byte
+	rsaKey  *rsa.PrivateKey
+}
+
+type jwtClaims struct {
+	UUID  string `json:"uuid"`
+	Count int    `json:"count"`
+	Hash  string `json:"hash"`
+	Exp   int64  `json:"exp"`
+}
+
+// NewSigner loads the signing key from keyPath for the given algorithm ("HS256"
+// or "RS256", case-insensitive; defaults to HS256 when empty).
+func NewSigner(keyPath, alg string) (*Signer, error) {
+	if alg == "" {
+		alg = "HS256"
+	}
+	s := &Signer{alg: strings.ToUpper(alg), keyPath: keyPath}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the key file from disk, swapping in the new key atomically.
+// Safe to call while batches are in flight or queued for retry.
+func (s *Signer) Reload() error {
+	raw, err := os.ReadFile(s.keyPath)
+	if err != nil {
+		return fmt.Errorf("read sign key: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.alg {
+	case "HS256":
+		s.hmacKey = raw
+	case "RS256":
+		block, _ := pem.Decode(raw)
+		if block == nil {
+			return fmt.Errorf("no PEM block found in %s", s.keyPath)
+		}
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			key8, err8 := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err8 != nil {
+				return fmt.Errorf("parse RSA key: %w", err)
+			}
+			rsaKey, ok := key8.(*rsa.PrivateKey)
+			if !ok {
+				return fmt.Errorf("PEM block is not an RSA private key")
+			}
+			key = rsaKey
+		}
+		s.rsaKey = key
+	default:
+		return fmt.Errorf("unsupported sign alg: %s", s.alg)
+	}
+	return nil
+}
+
+// StartRotation reloads the signing key every interval until stop is closed.
+// A failed reload is logged (if verbose) and the previous key stays in use.
+func (s *Signer) StartRotation(interval time.Duration, verbose bool, stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Reload(); err != nil && verbose {
+					logf("error", "key_rotate_error", "", "[!] Key rotate error: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func b64url(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Sign wraps payload in a compact JWT. Claims carry the UUID, event count, a
+// sha256 hash of payload, and an expiry, so the server can reject stale or
+// forged batches without trusting a bare UUID header.
+func (s *Signer) Sign(payload []byte, uuid string, eventCount int, ttl time.Duration) ([]byte, error) {
+	s.mu.RLock()
+	alg := s.alg
+	hmacKey := s.hmacKey
+	rsaKey := s.rsaKey
+	s.mu.RUnlock()
+
+	sum := sha256.Sum256(payload)
+	claims := jwtClaims{
+		UUID:  uuid,
+		Count: eventCount,
+		Hash:  fmt.Sprintf("%x", sum),
+		Exp:   time.Now().Add(ttl).Unix(),
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	header := fmt.Sprintf(`{"alg":%q,"typ":"JWT"}`, alg)
+	signingInput := b64url([]byte(header)) + "." + b64url(claimsJSON)
+
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, hmacKey)
+		mac.Write([]byte(signingInput))
+		sig = mac.Sum(nil)
+	case "RS256":
+		if rsaKey == nil {
+			return nil, fmt.Errorf("no RSA key loaded")
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			return nil, fmt.Errorf("rsa sign: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sign alg: %s", alg)
+	}
+
+	return []byte(signingInput + "." + b64url(sig)), nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeKeyFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sign.key")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	return path
+}
+
+func TestSignerHS256RoundTrip(t *testing.T) {
+	keyPath := writeKeyFile(t, "super-secret-key")
+	s, err := NewSigner(keyPath, "HS256")
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+
+	payload := []byte(`{"event":"login"}`)
+	token, err := s.Sign(payload, "batch-uuid", 3, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	parts := strings.Split(string(token), ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 dot-separated JWT segments, got %d: %q", len(parts), token)
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("decode claims: %v", err)
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatalf("unmarshal claims: %v", err)
+	}
+	if claims.UUID != "batch-uuid" || claims.Count != 3 {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	mac := hmac.New(sha256.New, []byte("super-secret-key"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	wantSig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if parts[2] != wantSig {
+		t.Fatalf("signature mismatch: got %q want %q", parts[2], wantSig)
+	}
+}
+
+func TestSignerReloadPicksUpRotatedKey(t *testing.T) {
+	keyPath := writeKeyFile(t, "key-v1")
+	s, err := NewSigner(keyPath, "HS256")
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+
+	if err := os.WriteFile(keyPath, []byte("key-v2"), 0600); err != nil {
+		t.Fatalf("rewrite key file: %v", err)
+	}
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	token, err := s.Sign([]byte("payload"), "uuid", 1, time.Minute)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	parts := strings.Split(string(token), ".")
+
+	mac := hmac.New(sha256.New, []byte("key-v2"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	wantSig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if parts[2] != wantSig {
+		t.Fatalf("signature still uses old key after Reload")
+	}
+}
+
+func TestNewSignerUnsupportedAlg(t *testing.T) {
+	keyPath := writeKeyFile(t, "whatever")
+	if _, err := NewSigner(keyPath, "ES256"); err == nil {
+		t.Fatal("expected error for unsupported alg, got nil")
+	}
+}
+
+// TestNewSignerAlgIsCaseInsensitive guards the documented "case-insensitive"
+// -sign-alg contract: NewSigner must normalize before Reload's switch matches
+// against the literal "HS256"/"RS256" strings.
+func TestNewSignerAlgIsCaseInsensitive(t *testing.T) {
+	keyPath := writeKeyFile(t, "a-key")
+	if _, err := NewSigner(keyPath, "hs256"); err != nil {
+		t.Fatalf("NewSigner(%q): %v", "hs256", err)
+	}
+	if _, err := NewSigner(keyPath, "Hs256"); err != nil {
+		t.Fatalf("NewSigner(%q): %v", "Hs256", err)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchMeta carries the context a sink needs beyond the raw payload bytes —
+// currently just what HTTPSink puts in its JWT claims.
+type BatchMeta struct {
+	UUID  string
+	Count int
+}
+
+// Sink is anywhere a flushed batch can be delivered. -sink registers one or
+// more of these so a single relay can ship to the production API, a local
+// audit log, and a developer's laptop at the same time.
+type Sink interface {
+	Name() string
+	Send(payload []byte, meta BatchMeta) error
+}
+
+// ParseSinks splits a comma-separated -sink spec (http://..., file://...,
+// udp://..., ws://..., kafka://...) into concrete Sink implementations. Any
+// http(s) sink is signed with signer, same as the default single-sink path.
+func ParseSinks(spec string, signer *Signer) ([]Sink, error) {
+	var sinks []Sink
+	for _, raw := range strings.Split(spec, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse sink %q: %w", raw, err)
+		}
+
+		switch u.Scheme {
+		case "http", "https":
+			sinks = append(sinks, NewHTTPSink(raw, apiClient, signer))
+		case "file":
+			path := u.Path
+			if path == "" {
+				path = u.Opaque
+			}
+			fs, err := NewFileSink(path)
+			if err != nil {
+				return nil, fmt.Errorf("sink %q: %w", raw, err)
+			}
+			sinks = append(sinks, fs)
+		case "udp":
+			us, err := NewUDPSink(u.Host)
+			if err != nil {
+				return nil, fmt.Errorf("sink %q: %w", raw, err)
+			}
+			sinks = append(sinks, us)
+		case "ws":
+			path := u.Path
+			if path == "" {
+				path = "/"
+			}
+			ws, err := NewWebSocketSink(u.Host, path)
+			if err != nil {
+				return nil, fmt.Errorf("sink %q: %w", raw, err)
+			}
+			sinks = append(sinks, ws)
+		case "kafka":
+			sinks = append(sinks, NewKafkaSink(u.Host, strings.TrimPrefix(u.Path, "/")))
+		default:
+			return nil, fmt.Errorf("sink %q: unsupported scheme %q", raw, u.Scheme)
+		}
+	}
+	return sinks, nil
+}
+
+// --- HTTPSink: the original sendToAPI behavior, now one sink among several ---
+
+// HTTPSink POSTs each batch to a URL, optionally JWT-signing the body via
+// signer (see signer.go).
+type HTTPSink struct {
+	url    string
+	client *http.Client
+	signer *Signer
+}
+
+// NewHTTPSink builds a sink that POSTs to url using client, signing the body
+// with signer when non-nil.
+func NewHTTPSink(url string, client *http.Client, signer *Signer) *HTTPSink {
+	return &HTTPSink{url: url, client: client, signer: signer}
+}
+
+func (s *HTTPSink) Name() string { return "http:" + s.url }
+
+func (s *HTTPSink) Send(payload []byte, meta BatchMeta) error {
+	body := payload
+	contentType := "application/json"
+	if s.signer != nil {
+		signed, err := s.signer.Sign(payload, meta.UUID, meta.Count, 5*time.Minute)
+		if err == nil {
+			body = signed
+			contentType = "application/jwt"
+		}
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("upstream returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// --- FileSink: append-only, rotating by size ---
+
+const fileSinkMaxSize = 50 * 1024 * 1024 // 50MB per segment
+const fileSinkKeep = 5                   // rotated segments retained besides the active file
+
+// FileSink appends each batch as a newline-delimited JSON line to path,
+// rotating the file (path.1, path.2, ...) once it passes fileSinkMaxSize.
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	size int64
+}
+
+// NewFileSink opens (creating if needed) path for append.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open file sink: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("stat file sink: %w", err)
+	}
+	return &FileSink{path: path, f: f, size: info.Size()}, nil
+}
+
+func (s *FileSink) Name() string { return "file:" + s.path }
+
+func (s *FileSink) Send(payload []byte, meta BatchMeta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line := append(append([]byte(nil), payload...), '\n')
+	if _, err := s.f.Write(line); err != nil {
+		return fmt.Errorf("write file sink: %w", err)
+	}
+	s.size += int64(len(line))
+
+	if s.size >= fileSinkMaxSize {
+		if err := s.rotateLocked(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileSink) rotateLocked() error {
+	s.f.Close()
+
+	for i := fileSinkKeep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	os.Rename(s.path, s.path+".1")
+	os.Remove(fmt.Sprintf("%s.%d", s.path, fileSinkKeep+1))
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("reopen file sink: %w", err)
+	}
+	s.f = f
+	s.size = 0
+	return nil
+}
+
+// --- UDPSink: line-delimited, fire-and-forget ---
+
+// UDPSink writes each batch as a line-delimited UDP datagram to a fixed
+// destination, e.g. a local collector that doesn't need delivery guarantees.
+type UDPSink struct {
+	addr string
+	conn net.Conn
+}
+
+// NewUDPSink dials addr ("host:port").
+func NewUDPSink(addr string) (*UDPSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial udp sink: %w", err)
+	}
+	return &UDPSink{addr: addr, conn: conn}, nil
+}
+
+func (s *UDPSink) Name() string { return "udp:" + s.addr }
+
+func (s *UDPSink) Send(payload []byte, meta BatchMeta) error {
+	_, err := s.conn.Write(append(append([]byte(nil), payload...), '\n'))
+	return err
+}
+
+// --- WebSocketSink: broadcasts batches to connected live-dashboard clients ---
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocketSink runs its own HTTP server that upgrades connections on path
+// and broadcasts every Send'd batch as a text frame to all of them. It never
+// returns an error from Send — a dashboard tab that isn't open yet (or
+// vanished) shouldn't count as sink failure the way an HTTP 500 does.
+type WebSocketSink struct {
+	addr    string
+	path    string
+	mu      sync.Mutex
+	clients map[net.Conn]struct{}
+}
+
+// NewWebSocketSink binds addr and starts upgrading requests to path. Binding
+// happens synchronously so a port collision (another ws:// sink,
+// -metrics-addr, or anything else already listening there) surfaces as an
+// error from ParseSinks instead of the relay believing it has a working sink
+// that will never accept a connection.
+func NewWebSocketSink(addr, path string) (*WebSocketSink, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen websocket sink: %w", err)
+	}
+
+	ws := &WebSocketSink{addr: addr, path: path, clients: make(map[net.Conn]struct{})}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, ws.handleUpgrade)
+
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			logf("error", "websocket_sink", "", "websocket sink %s%s stopped: %v", addr, path, err)
+		}
+	}()
+
+	return ws, nil
+}
+
+func (s *WebSocketSink) Name() string { return "ws:" + s.addr + s.path }
+
+func (s *WebSocketSink) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket upgrade unsupported", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, "hijack failed", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+	if _, err := buf.WriteString(resp); err != nil || buf.Flush() != nil {
+		conn.Close()
+		return
+	}
+
+	s.mu.Lock()
+	s.clients[conn] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *WebSocketSink) Send(payload []byte, meta BatchMeta) error {
+	frame := encodeWSTextFrame(payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		if _, err := c.Write(frame); err != nil {
+			c.Close()
+			delete(s.clients, c)
+		}
+	}
+	return nil
+}
+
+// encodeWSTextFrame wraps payload in a single unmasked RFC6455 text frame
+// (server-to-client frames must not be masked).
+func encodeWSTextFrame(payload []byte) []byte {
+	var header []byte
+	n := len(payload)
+	switch {
+	case n < 126:
+		header = []byte{0x81, byte(n)}
+	case n <= 0xFFFF:
+		header = []byte{0x81, 126, byte(n >> 8), byte(n)}
+	default:
+		header = []byte{0x81, 127, 0, 0, 0, 0, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	return append(header, payload...)
+}
+
+// --- KafkaSink: not implemented in this build ---
+
+// KafkaSink is a placeholder. Producing to Kafka needs a client library
+// (e.g. sarama or kafka-go), and this tree has no go.mod / vendored deps to
+// pull one in from, so Send just reports that plainly instead of pretending
+// to work.
+type KafkaSink struct {
+	broker string
+	topic  string
+}
+
+// NewKafkaSink records the target broker/topic for error messages; it never
+// actually connects.
+func NewKafkaSink(broker, topic string) *KafkaSink {
+	return &KafkaSink{broker: broker, topic: topic}
+}
+
+func (s *KafkaSink) Name() string { return "kafka:" + s.broker + "/" + s.topic }
+
+func (s *KafkaSink) Send(payload []byte, meta BatchMeta) error {
+	return fmt.Errorf("kafka sink (%s/%s) not implemented: no Kafka client vendored in this build", s.broker, s.topic)
+}
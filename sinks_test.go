@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSinksRoutesBySchemeAndRejectsUnknown(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "out.jsonl")
+
+	sinks, err := ParseSinks("file://"+filePath, nil)
+	if err != nil {
+		t.Fatalf("ParseSinks: %v", err)
+	}
+	if len(sinks) != 1 {
+		t.Fatalf("expected 1 sink, got %d", len(sinks))
+	}
+	if _, ok := sinks[0].(*FileSink); !ok {
+		t.Fatalf("expected *FileSink, got %T", sinks[0])
+	}
+
+	if _, err := ParseSinks("carrier-pigeon://nowhere", nil); err == nil {
+		t.Fatal("expected an error for an unsupported scheme, got nil")
+	}
+}
+
+func TestParseSinksUDP(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	defer l.Close()
+
+	sinks, err := ParseSinks("udp://"+l.LocalAddr().String(), nil)
+	if err != nil {
+		t.Fatalf("ParseSinks: %v", err)
+	}
+	if len(sinks) != 1 {
+		t.Fatalf("expected 1 sink, got %d", len(sinks))
+	}
+	if _, ok := sinks[0].(*UDPSink); !ok {
+		t.Fatalf("expected *UDPSink, got %T", sinks[0])
+	}
+}
+
+// TestNewWebSocketSinkReportsBindFailure guards the fix where a port
+// collision was swallowed by a bare goroutine running ListenAndServe, so
+// ParseSinks reported success for a sink that would never accept a
+// connection.
+func TestNewWebSocketSinkReportsBindFailure(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+
+	if _, err := NewWebSocketSink(l.Addr().String(), "/ws"); err == nil {
+		t.Fatal("expected an error binding to an already-listening address, got nil")
+	}
+}
+
+func TestKafkaSinkSendReportsNotImplemented(t *testing.T) {
+	s := NewKafkaSink("broker:9092", "events")
+	if err := s.Send([]byte("x"), BatchMeta{}); err == nil {
+		t.Fatal("expected KafkaSink.Send to report it's unimplemented, got nil")
+	}
+}